@@ -3,6 +3,7 @@ module github.com/yohimik/webxash3d-fwgs/docker/cs-web-server
 go 1.25.1
 
 require (
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/jinzhu/configor v1.2.2
 	github.com/pion/ice/v4 v4.0.10
@@ -16,7 +17,6 @@ require (
 
 require (
 	github.com/BurntSushi/toml v1.2.1 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/pion/datachannel v1.5.10 // indirect
 	github.com/pion/dtls/v3 v3.0.7 // indirect
 	github.com/pion/mdns/v2 v2.0.7 // indirect
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// allowedOrigins is the CORS policy shared by the JSON API and the WebSocket
+// origin check, built from CORS_ALLOWED_ORIGINS at startup
+var allowedOrigins = new(web.CORSPolicy)
+
+// checkOrigin applies allowedOrigins to WebSocket upgrades. The upgrader
+// rejects everything it refuses with 403 before upgrading.
+func checkOrigin(r *http.Request) bool {
+	return allowedOrigins.CheckOrigin(r)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// etagCache remembers the content hash of static files until their
+// modification time or size changes
+type etagCache struct {
+	root    fs.FS
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+func newETagCache(root fs.FS) *etagCache {
+	return &etagCache{root: root, entries: make(map[string]etagEntry)}
+}
+
+// lookup returns the strong ETag for a regular file, hashing it on first
+// use or after it changed
+func (c *etagCache) lookup(name string, info fs.FileInfo) (string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.etag, nil
+	}
+
+	f, err := c.root.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+
+	c.mu.Lock()
+	c.entries[name] = etagEntry{modTime: info.ModTime(), size: info.Size(), etag: etag}
+	c.mu.Unlock()
+	return etag, nil
+}
+
+// cacheHeaders sets a content-hash ETag and Cache-Control on static files.
+// HTML is always revalidated, other files may be cached for maxAge. The
+// wrapped http.FileServer answers If-None-Match with 304 on its own.
+func cacheHeaders(root fs.FS, maxAge time.Duration, next http.Handler) http.Handler {
+	cache := newETagCache(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := web.StaticName(r.URL.Path)
+		info, err := fs.Stat(root, name)
+		if err == nil && info.IsDir() && strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+			info, err = fs.Stat(root, name)
+		}
+		if err == nil && info.Mode().IsRegular() {
+			if etag, err := cache.lookup(name, info); err == nil {
+				w.Header().Set("ETag", etag)
+			}
+			if path.Ext(name) == ".html" {
+				w.Header().Set("Cache-Control", "no-cache")
+			} else if maxAge > 0 {
+				w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// readiness holds a flag per subsystem that /readyz waits for. Each
+// subsystem flips its flag once it is initialized.
+var readiness struct {
+	config atomic.Bool
+	webrtc atomic.Bool
+	engine atomic.Bool
+}
+
+// healthzHandler reports that the HTTP server is up
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, "ok")
+}
+
+// readyzHandler reports 503 with the pending subsystems until all are ready
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	var pending []string
+	if !readiness.config.Load() {
+		pending = append(pending, "config")
+	}
+	if !readiness.webrtc.Load() {
+		pending = append(pending, "webrtc")
+	}
+	if !readiness.engine.Load() {
+		pending = append(pending, "engine")
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	if len(pending) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "not ready: %s", strings.Join(pending, ", "))
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
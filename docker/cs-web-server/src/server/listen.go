@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	stdnet "net"
+	"os"
+	"strings"
+)
+
+// listen opens a TCP listener for host:port, or a Unix domain socket when
+// addr looks like unix:/path/to/socket. A stale socket file is replaced.
+func listen(addr string) (stdnet.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("invalid listen address %q: missing socket path", addr)
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path) //nolint
+		}
+		return stdnet.Listen("unix", path)
+	}
+	if _, _, err := stdnet.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	return stdnet.Listen("tcp", addr)
+}
+
+// mustListen is listen for startup, where a bad address is fatal
+func mustListen(addr string) stdnet.Listener {
+	ln, err := listen(addr)
+	if err != nil {
+		log.Errorf("Failed to listen: %v", err)
+		panic(err)
+	}
+	return ln
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// maintenanceRetryAfter is suggested to clients turned away during maintenance
+const maintenanceRetryAfter = 60 * time.Second
+
+// maintenance turns new requests away while set. Hijacked WebSocket
+// connections never pass through the router again and keep running.
+var maintenance atomic.Bool
+
+// handleMaintenanceToggle flips maintenance mode on every SIGUSR1
+func handleMaintenanceToggle() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	for range signals {
+		if maintenance.Load() {
+			maintenance.Store(false)
+			log.Infof("Maintenance mode off")
+		} else {
+			maintenance.Store(true)
+			log.Infof("Maintenance mode on")
+		}
+	}
+}
+
+// maintenanceMode answers 503 to everything but /healthz while maintenance
+// mode is on
+func maintenanceMode(next http.Handler) http.Handler {
+	skip := func(r *http.Request) bool { return r.URL.Path == "/healthz" }
+	return web.Maintenance(&maintenance, maintenanceRetryAfter, skip, next)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// metrics holds the counters exposed on /metrics in the Prometheus text format
+type metrics struct {
+	mu       sync.Mutex
+	requests map[string]uint64
+
+	// websocketConnections doubles as the counter for the connection cap
+	websocketConnections web.Connections
+}
+
+var stats = &metrics{
+	requests: make(map[string]uint64),
+}
+
+// countRequest increments the request counter for a route. Static files are
+// counted under a single route to keep the label cardinality bounded.
+func (m *metrics) countRequest(route string) {
+	m.mu.Lock()
+	m.requests[route]++
+	m.mu.Unlock()
+}
+
+// metricsHandler writes all counters in the Prometheus text exposition format
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	stats.mu.Lock()
+	routes := make([]string, 0, len(stats.requests))
+	for route := range stats.requests {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	counts := make([]uint64, len(routes))
+	for i, route := range routes {
+		counts[i] = stats.requests[route]
+	}
+	stats.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP webxash_http_requests_total Total HTTP requests by route.")
+	fmt.Fprintln(w, "# TYPE webxash_http_requests_total counter")
+	for i, route := range routes {
+		fmt.Fprintf(w, "webxash_http_requests_total{route=%q} %d\n", route, counts[i])
+	}
+
+	fmt.Fprintln(w, "# HELP webxash_websocket_connections Active signalling WebSocket connections.")
+	fmt.Fprintln(w, "# TYPE webxash_websocket_connections gauge")
+	fmt.Fprintf(w, "webxash_websocket_connections %d\n", stats.websocketConnections.Open())
+}
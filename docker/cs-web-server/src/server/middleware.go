@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	stdnet "net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// concurrencyRetryAfter is suggested to clients turned away while saturated
+const concurrencyRetryAfter = 5 * time.Second
+
+// clientIPHeaders are the headers, in order of precedence, that carry the
+// client address set by a trusted reverse proxy. Empty unless configured,
+// since clients can send any of them themselves.
+var clientIPHeaders []string
+
+// getClientIP returns the client address, trusting clientIPHeaders.
+func getClientIP(r *http.Request) string {
+	return web.ClientIP(r, clientIPHeaders)
+}
+
+// statusRecorder captures the status code and body size of a response. It
+// keeps the Hijacker and Flusher of the wrapped writer so WebSocket upgrades
+// still work behind it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.size += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *statusRecorder) Hijack() (stdnet.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil && rec.status == 0 {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// limitConcurrency answers 503 once max requests are in flight. WebSocket
+// sessions are left out since they are long-lived and capped on their own,
+// and the probes so that a saturated server isn't restarted as dead.
+func limitConcurrency(max int, next http.Handler) http.Handler {
+	skip := func(r *http.Request) bool {
+		switch r.URL.Path {
+		case "/websocket", "/healthz", "/readyz":
+			return true
+		}
+		return false
+	}
+	return web.LimitConcurrency(max, concurrencyRetryAfter, skip, next)
+}
+
+// securityHeaders sets the hardening headers for pages served from the
+// static root. The CSP is left out when empty, the game needs wasm, worker
+// and blob sources that depend on the deployment.
+func securityHeaders(frameOptions, csp string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", frameOptions)
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if csp != "" {
+			h.Set("Content-Security-Policy", csp)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withoutWriteTimeout lifts the server WriteTimeout for routes that stream
+// large responses, like game archives from the static root. WebSocket routes
+// don't need it: the upgrader clears all deadlines after hijacking.
+func withoutWriteTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{}) //nolint
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequests writes an access log line per request at the given level:
+// "info", "debug" or "off". Static file hits are skipped unless logStatic.
+func logRequests(level string, logStatic bool, next http.Handler) http.Handler {
+	logf := log.Infof
+	switch level {
+	case "off":
+		return next
+	case "debug":
+		logf = log.Debugf
+	case "info":
+	default:
+		log.Warnf("Unknown access log level %q, using info", level)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !logStatic && !isAPIPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logf("%s %s %s %s %d %dB %s", requestIDFromContext(r.Context()), getClientIP(r), r.Method, r.URL.Path, rec.status, rec.size, time.Since(start))
+	})
+}
+
+// logSlowRequests warns about API requests that take longer than threshold.
+// The long-lived /websocket and static downloads, whose duration depends on
+// the client, are left out. Warnings need PION_LOG_WARN=sfu-ws to show.
+func logSlowRequests(threshold time.Duration, next http.Handler) http.Handler {
+	skip := func(r *http.Request) bool {
+		return r.URL.Path == "/websocket" || !isAPIPath(r.URL.Path)
+	}
+	report := func(r *http.Request, elapsed time.Duration) {
+		log.Warnf("Slow request %s %s %s %s took %s", requestIDFromContext(r.Context()), getClientIP(r), r.Method, r.URL.Path, elapsed)
+	}
+	return web.SlowRequests(threshold, skip, report, next)
+}
+
+// recoverPanics turns a panicking handler into a 500 response and logs the
+// stack trace. It only covers the handler goroutine: goroutines a handler
+// starts, such as the WebRTC data channel callbacks of /websocket, still
+// crash the process and must recover on their own.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if e, ok := err.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+				panic(err)
+			}
+			log.Errorf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			web.WriteJSONError(w, http.StatusInternalServerError, "internal_error", http.StatusText(http.StatusInternalServerError))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleReload rebuilds the served engine config on every SIGHUP. Reloads
+// run one at a time on this goroutine, and signals arriving meanwhile
+// collapse into one pending reload, so rebuilds never stampede. A future
+// trigger from another goroutine must keep that guarantee.
+func handleReload() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := reloadEngineConfig(); err != nil {
+			log.Errorf("Failed to reload engine config: %v", err)
+			continue
+		}
+		log.Infof("Reloaded engine config")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// apiRoute is an API endpoint served next to the static files
+type apiRoute struct {
+	path    string
+	methods []string
+	handler http.HandlerFunc
+
+	// cors opens the route to the CORS_ALLOWED_ORIGINS and answers their
+	// preflight requests
+	cors bool
+	// timeout bounds the handler by API_TIMEOUT
+	timeout bool
+}
+
+// apiRoutes is the single list of API endpoints. Routing, isAPIPath and the
+// CORS preflights are all derived from it, so adding a route here is enough.
+var apiRoutes = []apiRoute{
+	{path: "/websocket", methods: []string{http.MethodGet}, handler: websocketHandler},
+	{path: "/config", methods: []string{http.MethodGet}, handler: configHandler, cors: true, timeout: true},
+	{path: "/metrics", methods: []string{http.MethodGet}, handler: metricsHandler},
+	{path: "/healthz", methods: []string{http.MethodGet}, handler: healthzHandler},
+	{path: "/readyz", methods: []string{http.MethodGet}, handler: readyzHandler},
+	{path: "/version", methods: []string{http.MethodGet}, handler: versionHandler},
+}
+
+// apiPaths are the paths of apiRoutes, which isAPIPath classifies against
+var apiPaths = func() []string {
+	paths := make([]string, len(apiRoutes))
+	for i, route := range apiRoutes {
+		paths[i] = route.path
+	}
+	return paths
+}()
+
+// isAPIPath reports whether p is an API route or lives below one
+func isAPIPath(p string) bool {
+	return web.IsAPIPath(apiPaths, p)
+}
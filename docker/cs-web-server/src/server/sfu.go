@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/jinzhu/configor"
@@ -12,14 +13,15 @@ import (
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v4"
 	"github.com/yohimik/goxash3d-fwgs/pkg"
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -50,6 +52,15 @@ func (n *SFUNet) SendTo(fd int, packet goxash3d_fwgs.Packet, flags int) int {
 	return nn
 }
 
+// Bind marks the engine as ready once it binds its server socket
+func (n *SFUNet) Bind(fd int, addr goxash3d_fwgs.Addr) int {
+	res := n.BaseNet.Bind(fd, addr)
+	if res == 0 {
+		readiness.engine.Store(true)
+	}
+	return res
+}
+
 func (n *SFUNet) SendToBatch(fd int, packets []goxash3d_fwgs.Packet, flags int) int {
 	sum := 0
 	for _, packet := range packets {
@@ -66,9 +77,9 @@ var pool = goxash3d_fwgs.NewBytesPool(256)
 var connections = make([]io.Writer, 256)
 
 var (
-	addr     = ":27016"
 	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
+		CheckOrigin:  checkOrigin,
+		Subprotocols: websocketSubprotocols,
 	}
 
 	api *webrtc.API
@@ -265,16 +276,59 @@ func ReadLoop(d io.Reader, ip [4]byte) {
 
 // Handle incoming websockets.
 func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
+	// Register before upgrading so shutdown can't miss a handler in flight
+	websockets.Add(1)
+	defer websockets.Done()
+
+	if !web.NegotiableSubprotocol(r, websocketSubprotocols) {
+		web.WriteJSONError(w, http.StatusBadRequest, "unsupported_subprotocol", "No supported WebSocket subprotocol offered")
+
+		return
+	}
+
+	if !stats.websocketConnections.Acquire(appConfig.Server.MaxWebsockets) {
+		rejectConnection(w, http.StatusServiceUnavailable, "server_full", "Too many connections")
+
+		return
+	}
+	defer stats.websocketConnections.Release()
+
+	clientIP := getClientIP(r)
+	if n, ok := websocketsPerIP.Acquire(clientIP, appConfig.Server.MaxWebsocketsPerIP); !ok {
+		log.Warnf("Rejecting Websocket from %s: %d connections already open", clientIP, n)
+		rejectConnection(w, http.StatusTooManyRequests, "rate_limited", "Too many connections from your address")
+
+		return
+	}
+	defer websocketsPerIP.Release(clientIP)
+
 	// Upgrade HTTP request to Websocket
 	unsafeConn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Errorf("Failed to upgrade HTTP to Websocket: ", err)
+		log.Errorf("Failed to upgrade HTTP to Websocket (request %s): %v", requestIDFromContext(r.Context()), err)
 
 		return
 	}
 
 	c := &threadSafeWriter{unsafeConn, sync.Mutex{}} // nolint
 
+	// Register for the goodbye sent on shutdown, which may already be underway
+	if !websocketHub.Add(c.Conn) {
+		web.CloseGoingAway(c.Conn, "server shutting down")
+
+		return
+	}
+	defer websocketHub.Remove(c.Conn)
+
+	// Detect dead peers
+	closed := make(chan struct{})
+	defer close(closed)
+	web.ExpectPongs(c.Conn, appConfig.Server.WSPongTimeout)
+	go web.KeepAlive(c.Conn, appConfig.Server.WSPingInterval, closed)
+
+	// Oversized frames make ReadMessage fail and close with 1009
+	c.SetReadLimit(appConfig.Server.WSMaxMessageSize)
+
 	// When this frame returns close the Websocket
 	defer c.Close() //nolint
 
@@ -311,7 +365,12 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 	for i := range ip {
 		ip[i] = byte(rand.Intn(256))
 	}
-	index, _ := pool.TryGet()
+	index, err := pool.TryGet()
+	if err != nil {
+		log.Errorf("Failed to allocate a client address: %v", err)
+
+		return
+	}
 	ip[0] = index
 	defer pool.TryPut(index)
 
@@ -422,6 +481,7 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 	signalPeerConnections()
 
 	message := &websocketMessage{}
+	limiter := web.NewMessageLimiter(appConfig.Server.WSMaxMessageRate)
 	for {
 		_, raw, err := c.ReadMessage()
 		if err != nil {
@@ -430,6 +490,13 @@ func websocketHandler(w http.ResponseWriter, r *http.Request) { // nolint
 			return
 		}
 
+		if !limiter.Allow(time.Now()) {
+			log.Errorf("Closing Websocket of %s: too many messages", getClientIP(r))
+			closeWithPolicyViolation(c, "too many messages")
+
+			return
+		}
+
 		if err := json.Unmarshal(raw, &message); err != nil {
 			log.Errorf("Failed to unmarshal json to message: %v", err)
 
@@ -501,6 +568,40 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 
 // Config holds the application configuration
 type Config struct {
+	Server struct {
+		ListenAddr         string        `env:"LISTEN_ADDR" default:":27016"`
+		ShutdownTimeout    time.Duration `env:"SHUTDOWN_TIMEOUT" default:"10s"`
+		SPAFallback        bool          `env:"SPA_FALLBACK"`
+		DisableCompression bool          `env:"DISABLE_COMPRESSION"`
+		CompressionMinSize int           `env:"COMPRESSION_MIN_SIZE" default:"1024"`
+		StaticMaxAge       time.Duration `env:"STATIC_MAX_AGE"`
+		WebRoot            string        `env:"WEB_ROOT" default:"public"`
+		CORSOrigins        string        `env:"CORS_ALLOWED_ORIGINS"`
+		CORSCredentials    bool          `env:"CORS_ALLOW_CREDENTIALS"`
+		AccessLog          string        `env:"ACCESS_LOG" default:"info"`
+		AccessLogStatic    bool          `env:"ACCESS_LOG_STATIC"`
+		SlowRequest        time.Duration `env:"SLOW_REQUEST_THRESHOLD" default:"2s"`
+		Maintenance        bool          `env:"MAINTENANCE_MODE"`
+		ClientIPHeaders    string        `env:"CLIENT_IP_HEADERS"`
+		APITimeout         time.Duration `env:"API_TIMEOUT" default:"5s"`
+		ReadHeaderTimeout  time.Duration `env:"READ_HEADER_TIMEOUT" default:"10s"`
+		ReadTimeout        time.Duration `env:"READ_TIMEOUT" default:"30s"`
+		WriteTimeout       time.Duration `env:"WRITE_TIMEOUT" default:"30s"`
+		IdleTimeout        time.Duration `env:"IDLE_TIMEOUT" default:"120s"`
+		TLSCert            string        `env:"TLS_CERT"`
+		TLSKey             string        `env:"TLS_KEY"`
+		TLSAddr            string        `env:"TLS_ADDR" default:":443"`
+		RedirectAddr       string        `env:"HTTP_REDIRECT_ADDR" default:":80"`
+		FrameOptions       string        `env:"X_FRAME_OPTIONS" default:"SAMEORIGIN"`
+		CSP                string        `env:"CONTENT_SECURITY_POLICY"`
+		WSPingInterval     time.Duration `env:"WS_PING_INTERVAL" default:"30s"`
+		WSPongTimeout      time.Duration `env:"WS_PONG_TIMEOUT" default:"75s"`
+		MaxWebsockets      int           `env:"MAX_WEBSOCKET_CONNECTIONS" default:"256"`
+		MaxWebsocketsPerIP int           `env:"MAX_WEBSOCKET_CONNECTIONS_PER_IP"`
+		MaxRequests        int           `env:"MAX_CONCURRENT_REQUESTS" default:"1024"`
+		WSMaxMessageSize   int64         `env:"WS_MAX_MESSAGE_SIZE" default:"65536"`
+		WSMaxMessageRate   int           `env:"WS_MAX_MESSAGE_RATE" default:"50"`
+	}
 	Engine struct {
 		Arguments string `env:"ENGINE_ARGS" required:"false"`
 		Console   string `env:"ENGINE_CONSOLE" required:"false"`
@@ -528,14 +629,56 @@ type EngineConfig struct {
 }
 
 var (
-	appConfig        Config
-	engineConfigJSON []byte
+	appConfig Config
+
+	// engineConfigJSON is swapped as a whole on reload so readers never see
+	// a partially written value
+	engineConfigJSON atomic.Pointer[web.ConfigPayload]
 )
 
-// configHandler returns the pre-serialized engine configuration
+// configHandler returns the pre-serialized engine configuration, gzipped
+// when the client accepts it
 func configHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(engineConfigJSON)
+	engineConfigJSON.Load().ServeHTTP(w, r)
+}
+
+// configFiles lists the optional CONFIG_FILE entries loaded before the env
+func configFiles() []string {
+	return sliceArgs(os.Getenv("CONFIG_FILE"))
+}
+
+// buildEngineConfigJSON serializes the engine part of the configuration
+func buildEngineConfigJSON(cfg *Config) ([]byte, error) {
+	engineConfig := EngineConfig{
+		Arguments: sliceArgs(cfg.Engine.Arguments),
+		Console:   sliceArgs(cfg.Engine.Console),
+		GameDir:   cfg.Engine.GameDir,
+		Libraries: map[string]string{
+			"client":     cfg.Libraries.Client,
+			"server":     cfg.Libraries.Server,
+			"extras":     cfg.Libraries.Extras,
+			"menu":       cfg.Libraries.Menu,
+			"filesystem": cfg.Libraries.Filesystem,
+		},
+		DynamicLibraries: sliceArgs(cfg.Libraries.DynamicLibraries),
+		FilesMap:         parseFilesMap(cfg.Libraries.FilesMap),
+	}
+	return json.Marshal(engineConfig)
+}
+
+// reloadEngineConfig re-reads the configuration sources and swaps the served
+// engine config. Server settings still need a restart to change.
+func reloadEngineConfig() error {
+	var cfg Config
+	if err := configor.Load(&cfg, configFiles()...); err != nil {
+		return err
+	}
+	data, err := buildEngineConfigJSON(&cfg)
+	if err != nil {
+		return err
+	}
+	engineConfigJSON.Store(web.NewConfigPayload(data))
+	return nil
 }
 
 // sliceArgs converts a comma-separated string into a slice of strings
@@ -570,73 +713,120 @@ func parseFilesMap(value string) map[string]string {
 }
 
 type Server struct {
+	mux     *http.ServeMux
+	handler http.Handler
 }
 
-var disabledXPoweredBy = false
-var xPoweredByValue = "yohimik"
+// NewServer registers every route with the HTTP methods it accepts. Requests
+// with another method get 405 Method Not Allowed and an Allow header.
+func NewServer(static http.Handler) *Server {
+	s := &Server{mux: http.NewServeMux()}
+	for _, route := range apiRoutes {
+		var h http.Handler = route.handler
+		if route.timeout {
+			h = web.WithTimeout(appConfig.Server.APITimeout, h)
+		}
+		if route.cors {
+			h = allowedOrigins.Handler(h)
+			if allowedOrigins.Enabled() {
+				s.handle("OPTIONS "+route.path, route.path, allowedOrigins.Preflight(route.methods...))
+			}
+		}
+		for _, method := range route.methods {
+			s.handle(method+" "+route.path, route.path, h)
+		}
+	}
+	s.handle("GET /", "static", withoutWriteTimeout(static))
+
+	// Wrap the router in the middlewares, innermost first
+	var h http.Handler = staticPathGuard(publicFS, s.mux)
+	h = strictTransportSecurity(h)
+	h = recoverPanics(h)
+	h = limitConcurrency(appConfig.Server.MaxRequests, h)
+	h = maintenanceMode(h)
+	h = logRequests(appConfig.Server.AccessLog, appConfig.Server.AccessLogStatic, h)
+	h = logSlowRequests(appConfig.Server.SlowRequest, h)
+	s.handler = withRequestID(h)
+	return s
+}
+
+// handle registers a handler and counts its requests under the route name
+func (s *Server) handle(pattern, route string, h http.Handler) {
+	s.mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats.countRequest(route)
+		h.ServeHTTP(w, r)
+	}))
+}
+
+// xPoweredBy is the X-Powered-By header value, empty omits the header
+var xPoweredBy = "yohimik"
 
 func init() {
 	// Load server configuration
-	disable, _ := os.LookupEnv("DISABLE_X_POWERED_BY")
-	if disable == "true" {
-		disabledXPoweredBy = true
-	}
-	xPoweredValue, has := os.LookupEnv("X_POWERED_BY_VALUE")
-	if has {
-		xPoweredByValue = xPoweredValue
-	}
+	xPoweredBy = web.XPoweredByFromEnv(xPoweredBy)
 
 	// Load engine configuration using configor
-	if err := configor.Load(&appConfig); err != nil {
+	err := configor.Load(&appConfig, configFiles()...)
+	if err != nil {
 		log.Errorf("Failed to load configuration: %v", err)
 		panic(err)
 	}
 
-	// Build and serialize the engine config JSON once
-	engineConfig := EngineConfig{
-		Arguments: sliceArgs(appConfig.Engine.Arguments),
-		Console:   sliceArgs(appConfig.Engine.Console),
-		GameDir:   appConfig.Engine.GameDir,
-		Libraries: map[string]string{
-			"client":     appConfig.Libraries.Client,
-			"server":     appConfig.Libraries.Server,
-			"extras":     appConfig.Libraries.Extras,
-			"menu":       appConfig.Libraries.Menu,
-			"filesystem": appConfig.Libraries.Filesystem,
-		},
-		DynamicLibraries: sliceArgs(appConfig.Libraries.DynamicLibraries),
-		FilesMap:         parseFilesMap(appConfig.Libraries.FilesMap),
+	if appConfig.Server.WSPingInterval <= 0 || appConfig.Server.WSPongTimeout <= appConfig.Server.WSPingInterval {
+		err = fmt.Errorf("WS_PONG_TIMEOUT (%v) must exceed a positive WS_PING_INTERVAL (%v)",
+			appConfig.Server.WSPongTimeout, appConfig.Server.WSPingInterval)
+		log.Errorf("Invalid configuration: %v", err)
+		panic(err)
 	}
 
-	var err error
-	engineConfigJSON, err = json.Marshal(engineConfig)
+	if (appConfig.Server.TLSCert == "") != (appConfig.Server.TLSKey == "") {
+		err = errors.New("TLS_CERT and TLS_KEY must be set together")
+		log.Errorf("Invalid configuration: %v", err)
+		panic(err)
+	}
+
+	// Every session needs one of the pool's client addresses
+	if appConfig.Server.MaxWebsockets <= 0 || appConfig.Server.MaxWebsockets > pool.Capacity() {
+		appConfig.Server.MaxWebsockets = pool.Capacity()
+	}
+
+	for _, name := range sliceArgs(appConfig.Server.ClientIPHeaders) {
+		clientIPHeaders = append(clientIPHeaders, http.CanonicalHeaderKey(name))
+	}
+	allowedOrigins, err = web.NewCORSPolicy(sliceArgs(appConfig.Server.CORSOrigins), appConfig.Server.CORSCredentials)
+	if err != nil {
+		log.Errorf("Invalid CORS configuration: %v", err)
+		panic(err)
+	}
+
+	if err := web.RegisterStaticTypes(); err != nil {
+		panic(err)
+	}
+	publicFS, err = loadPublicFS(appConfig.Server.WebRoot)
+	if err != nil {
+		log.Errorf("Failed to open web root: %v", err)
+		panic(err)
+	}
+	if !web.HasIndex(publicFS) {
+		log.Errorf("Web root %q has no index.html, / will show an error page until it is deployed", appConfig.Server.WebRoot)
+	}
+
+	// Build and serialize the engine config JSON once, until a reload
+	data, err := buildEngineConfigJSON(&appConfig)
 	if err != nil {
 		log.Errorf("Failed to serialize config: %v", err)
 		panic(err)
 	}
+	engineConfigJSON.Store(web.NewConfigPayload(data))
+	maintenance.Store(appConfig.Server.Maintenance)
+	readiness.config.Store(true)
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !disabledXPoweredBy {
-		w.Header().Set("X-Powered-By", xPoweredByValue)
-	}
-	switch r.URL.Path {
-	case "/websocket":
-		websocketHandler(w, r)
-	case "/config":
-		configHandler(w, r)
-	default:
-		p := r.URL.Path
-		if r.URL.Path == "/" {
-			p = "index.html"
-		}
-		path := filepath.Join("public", p)
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			http.NotFound(w, r)
-			return
-		}
-		http.ServeFile(w, r, path)
+	if xPoweredBy != "" {
+		w.Header().Set("X-Powered-By", xPoweredBy)
 	}
+	s.handler.ServeHTTP(w, r)
 }
 
 func runSFU() {
@@ -672,10 +862,14 @@ func runSFU() {
 		panic(err)
 	}
 	api = webrtc.NewAPI(webrtc.WithSettingEngine(settingEngine), webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i))
+	readiness.webrtc.Store(true)
 
 	// Init other state
 	trackLocals = map[string]*webrtc.TrackLocalStaticRTP{}
 
+	go handleReload()
+	go handleMaintenanceToggle()
+
 	// request a keyframe every 3 seconds
 	go func() {
 		for range time.NewTicker(time.Second * 3).C {
@@ -684,7 +878,47 @@ func runSFU() {
 	}()
 
 	// start HTTP server
-	if err := http.ListenAndServe(addr, &Server{}); err != nil { //nolint: gosec
-		log.Errorf("Failed to start http server: %v", err)
+	static := cacheHeaders(publicFS, appConfig.Server.StaticMaxAge, http.FileServer(http.FS(publicFS)))
+	static = web.IndexFallback(publicFS, static)
+	static = notFoundPage(publicFS, static)
+	if appConfig.Server.SPAFallback {
+		static = spaFallback(publicFS, static)
+	}
+	if !appConfig.Server.DisableCompression {
+		static = web.Compress(appConfig.Server.CompressionMinSize, static)
+	}
+	static = securityHeaders(appConfig.Server.FrameOptions, appConfig.Server.CSP, static)
+	srv := &http.Server{
+		Handler:           NewServer(static),
+		ReadHeaderTimeout: appConfig.Server.ReadHeaderTimeout,
+		ReadTimeout:       appConfig.Server.ReadTimeout,
+		WriteTimeout:      appConfig.Server.WriteTimeout,
+		IdleTimeout:       appConfig.Server.IdleTimeout,
+	}
+	if appConfig.Server.TLSCert == "" {
+		ln := mustListen(appConfig.Server.ListenAddr)
+		go handleShutdown(srv)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Failed to start http server: %v", err)
+		}
+		return
+	}
+
+	// serve TLS and redirect plain HTTP to it
+	ln := mustListen(appConfig.Server.TLSAddr)
+	redirectLn := mustListen(appConfig.Server.RedirectAddr)
+	redirect := &http.Server{
+		Handler:           httpsRedirect(appConfig.Server.TLSAddr),
+		ReadHeaderTimeout: appConfig.Server.ReadHeaderTimeout,
+		IdleTimeout:       appConfig.Server.IdleTimeout,
+	}
+	go func() {
+		if err := redirect.Serve(redirectLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Failed to start http redirect server: %v", err)
+		}
+	}()
+	go handleShutdown(srv, redirect)
+	if err := srv.ServeTLS(ln, appConfig.Server.TLSCert, appConfig.Server.TLSKey); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("Failed to start https server: %v", err)
 	}
 }
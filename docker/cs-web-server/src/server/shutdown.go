@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// websockets tracks running WebSocket handlers, which http.Server.Shutdown does
+// not wait for because their connections are hijacked
+var websockets sync.WaitGroup
+
+// handleShutdown waits for SIGINT/SIGTERM, drains the HTTP servers within the
+// configured grace period and exits the process
+func handleShutdown(servers ...*http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	log.Infof("Received %v, shutting down", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), appConfig.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := web.Drain(ctx, websocketHub, &websockets, servers...); err != nil {
+		log.Warnf("Shutdown did not finish cleanly: %v", err)
+	}
+
+	os.Exit(0)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// publicFS is the root static files are served from. It reads WEB_ROOT
+// from disk unless the binary is built with the embed tag.
+var publicFS fs.FS
+
+// staticPathGuard answers 404 for paths that try to climb out of the static
+// root instead of letting them resolve to something inside it. It has to
+// wrap the router, which would otherwise redirect them to their cleaned form.
+func staticPathGuard(root fs.FS, next http.Handler) http.Handler {
+	return web.PathGuard(func(w http.ResponseWriter, r *http.Request) {
+		web.NotFound(root, isAPIPath, w, r)
+	}, next)
+}
+
+// notFoundPage answers requests for missing files with the custom 404
+// page or the JSON envelope instead of the bare 404 of http.FileServer
+func notFoundPage(root fs.FS, next http.Handler) http.Handler {
+	return web.NotFoundPage(root, isAPIPath, next)
+}
+
+// spaFallback serves index.html for paths that don't match a file so the
+// client-side router can handle them
+func spaFallback(root fs.FS, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := fs.Stat(root, web.StaticName(r.URL.Path)); errors.Is(err, fs.ErrNotExist) && !isAPIPath(r.URL.Path) {
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = "/"
+			r2.URL.RawPath = ""
+			r = r2
+		}
+		next.ServeHTTP(w, r)
+	})
+}
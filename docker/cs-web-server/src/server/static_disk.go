@@ -0,0 +1,27 @@
+//go:build !embed
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// loadPublicFS resolves the static root to an absolute directory on disk
+func loadPublicFS(root string) (fs.FS, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", abs)
+	}
+	log.Infof("Serving static files from %s", abs)
+	return os.DirFS(abs), nil
+}
@@ -0,0 +1,20 @@
+//go:build embed
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Copy the client build into src/server/public before building with
+// `go build -tags embed` to get a self-contained binary.
+//
+//go:embed all:public
+var embeddedPublic embed.FS
+
+// loadPublicFS ignores the configured root and serves the embedded files
+func loadPublicFS(string) (fs.FS, error) {
+	log.Infof("Serving embedded static files")
+	return fs.Sub(embeddedPublic, "public")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	stdnet "net"
+	"net/http"
+)
+
+// httpsRedirect sends every plain HTTP request to the same path and query on
+// the TLS listener at tlsAddr
+func httpsRedirect(tlsAddr string) http.Handler {
+	_, tlsPort, _ := stdnet.SplitHostPort(tlsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := stdnet.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host = stdnet.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// strictTransportSecurity asks browsers to stick to HTTPS on TLS responses
+func strictTransportSecurity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+)
+
+// Build metadata, set at build time with
+//
+//	go build -ldflags "-X main.gitCommit=... -X main.buildTime=..."
+var (
+	gitCommit = "dev"
+	buildTime = "dev"
+)
+
+// versionHandler reports which build of the server is running
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct { //nolint
+		Commit    string `json:"commit"`
+		BuildTime string `json:"buildTime"`
+		GoVersion string `json:"goVersion"`
+	}{gitCommit, buildTime, runtime.Version()})
+}
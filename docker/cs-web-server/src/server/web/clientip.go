@@ -0,0 +1,35 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIP returns the client address from the first of headers holding a
+// valid IP, falling back to the peer that sent the request. For
+// comma-separated lists such as X-Forwarded-For the last entry is used, the
+// one appended by the proxy closest to the server. Only pass headers a
+// trusted proxy sets, clients can send any of them themselves.
+func ClientIP(r *http.Request, headers []string) string {
+	for _, name := range headers {
+		value := r.Header.Values(name)
+		if len(value) == 0 {
+			continue
+		}
+		last := value[len(value)-1]
+		if i := strings.LastIndexByte(last, ','); i >= 0 {
+			last = last[i+1:]
+		}
+		if addr, err := netip.ParseAddr(strings.TrimSpace(last)); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
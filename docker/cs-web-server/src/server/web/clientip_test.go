@@ -0,0 +1,75 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    []string
+		set        map[string]string
+		remoteAddr string
+		want       string
+	}{
+		{
+			name:       "no headers configured",
+			set:        map[string]string{"X-Forwarded-For": "198.51.100.7"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "last forwarded entry",
+			headers:    []string{"X-Forwarded-For"},
+			set:        map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.7"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "header order",
+			headers:    []string{"CF-Connecting-IP", "X-Forwarded-For"},
+			set:        map[string]string{"CF-Connecting-IP": "203.0.113.5", "X-Forwarded-For": "198.51.100.7"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "invalid value falls through",
+			headers:    []string{"CF-Connecting-IP", "X-Forwarded-For"},
+			set:        map[string]string{"CF-Connecting-IP": "unknown", "X-Forwarded-For": "198.51.100.7"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "invalid values fall back to the peer",
+			headers:    []string{"X-Real-IP"},
+			set:        map[string]string{"X-Real-IP": "198.51.100.7:80"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "mapped IPv4",
+			headers:    []string{"X-Real-IP"},
+			set:        map[string]string{"X-Real-IP": "::ffff:198.51.100.7"},
+			remoteAddr: "192.0.2.1:1234",
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "IPv6 peer",
+			remoteAddr: "[2001:db8::1]:1234",
+			want:       "2001:db8::1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.set {
+				r.Header.Set(k, v)
+			}
+			if got := ClientIP(r, tt.headers); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// incompressibleTypes are content types that are already compressed
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// AcceptsGzip reports whether the Accept-Encoding header allows gzip
+func AcceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.TrimSpace(coding)
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isCompressible reports whether a response of the given type is worth gzipping
+func isCompressible(contentType string) bool {
+	if contentType == "image/svg+xml" {
+		return true
+	}
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compress gzips responses of at least minSize bytes for clients that accept
+// it. Partial and already encoded responses are passed through.
+func Compress(minSize int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !AcceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK, head: r.Method == http.MethodHead}
+		// Gzipped responses carry a suffixed ETag, match them against the
+		// identity ETag the wrapped handler knows about. A 304 for such a
+		// tag confirms the gzipped copy and has to repeat its suffixed ETag.
+		if inm := r.Header.Get("If-None-Match"); strings.Contains(inm, `-gzip"`) {
+			r.Header.Set("If-None-Match", strings.ReplaceAll(inm, `-gzip"`, `"`))
+			cw.revalidatesGzip = true
+		}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// response is large enough to be compressed
+type compressWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+
+	// head marks HEAD requests, which get the headers GET would get
+	head bool
+	// revalidatesGzip is set when If-None-Match names a gzipped copy
+	revalidatesGzip bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// size is the length of the response body as far as it is known: what was
+// buffered, or the Content-Length of a body-less HEAD response
+func (cw *compressWriter) size() int {
+	if cw.head && len(cw.buf) == 0 {
+		n, _ := strconv.Atoi(cw.Header().Get("Content-Length"))
+		return n
+	}
+	return len(cw.buf)
+}
+
+// decide sends the headers, switching to gzip when the buffered response is
+// eligible, and flushes whatever was buffered so far
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+	switch {
+	case cw.status == http.StatusOK && cw.size() >= cw.minSize &&
+		h.Get("Content-Encoding") == "" && isCompressible(h.Get("Content-Type")):
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		gzipETag(h)
+		if !cw.head {
+			cw.gz = gzipWriters.Get().(*gzip.Writer)
+			cw.gz.Reset(cw.ResponseWriter)
+		}
+	case cw.status == http.StatusNotModified && cw.revalidatesGzip:
+		gzipETag(h)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.gz != nil {
+		_, err = cw.gz.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+// gzipETag suffixes the ETag so the gzipped copy has a validator of its own
+func gzipETag(h http.Header) {
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", strings.TrimSuffix(etag, `"`)+`-gzip"`)
+	}
+}
+
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		cw.decide() //nolint
+	}
+	if cw.gz != nil {
+		cw.gz.Close() //nolint
+		gzipWriters.Put(cw.gz)
+	}
+}
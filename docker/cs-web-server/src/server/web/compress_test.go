@@ -0,0 +1,186 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// asset serves body like http.FileServer does, with a fixed ETag
+func asset(body []byte, contentType string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, "asset", time.Time{}, bytes.NewReader(body))
+	})
+}
+
+func compressRequest(method string, headers ...string) *http.Request {
+	r := httptest.NewRequest(method, "/asset", nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return r
+}
+
+var largeText = []byte(strings.Repeat("webxash ", 512))
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"*", true},
+		{"gzip;q=0", false},
+		{"br", false},
+	}
+	for _, tt := range tests {
+		if got := AcceptsGzip(tt.header); got != tt.want {
+			t.Errorf("AcceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompressGzipsLargeResponses(t *testing.T) {
+	h := Compress(1024, asset(largeText, "text/plain; charset=utf-8"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, compressRequest(http.MethodGet, "Accept-Encoding", "gzip"))
+
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := w.Header().Get("ETag"); got != `"v1-gzip"` {
+		t.Errorf("ETag = %q, want \"v1-gzip\"", got)
+	}
+	if got := w.Header().Get("Content-Length"); got != "" {
+		t.Errorf("Content-Length = %q, want none", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", got)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := io.ReadAll(zr); err != nil || !bytes.Equal(body, largeText) {
+		t.Errorf("decompressed body differs from the original (err %v)", err)
+	}
+}
+
+func TestCompressPassesThrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		typ     string
+		headers []string
+		status  int
+	}{
+		{"no Accept-Encoding", largeText, "text/plain", nil, http.StatusOK},
+		{"gzip refused", largeText, "text/plain", []string{"Accept-Encoding", "gzip;q=0"}, http.StatusOK},
+		{"below minSize", []byte("small"), "text/plain", []string{"Accept-Encoding", "gzip"}, http.StatusOK},
+		{"already compressed", largeText, "image/png", []string{"Accept-Encoding", "gzip"}, http.StatusOK},
+		{"range", largeText, "text/plain", []string{"Accept-Encoding", "gzip", "Range", "bytes=0-99"}, http.StatusPartialContent},
+	}
+	for _, tt := range tests {
+		h := Compress(1024, asset(tt.body, tt.typ))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, compressRequest(http.MethodGet, tt.headers...))
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s: Content-Encoding = %q, want none", tt.name, got)
+		}
+		if got := w.Header().Get("ETag"); got != `"v1"` {
+			t.Errorf("%s: ETag = %q, want \"v1\"", tt.name, got)
+		}
+	}
+}
+
+func TestCompressRevalidation(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        string
+	}{
+		{`"v1-gzip"`, `"v1-gzip"`},
+		{`W/"v1-gzip"`, `"v1-gzip"`},
+		{`"v1"`, `"v1"`},
+	}
+	for _, tt := range tests {
+		h := Compress(1024, asset(largeText, "text/plain"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, compressRequest(http.MethodGet, "Accept-Encoding", "gzip", "If-None-Match", tt.ifNoneMatch))
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status = %d, want 304", tt.ifNoneMatch, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got != tt.want {
+			t.Errorf("If-None-Match %s: ETag = %q, want %q", tt.ifNoneMatch, got, tt.want)
+		}
+	}
+}
+
+func TestCompressHeadMatchesGet(t *testing.T) {
+	h := Compress(1024, asset(largeText, "text/plain"))
+	get := httptest.NewRecorder()
+	h.ServeHTTP(get, compressRequest(http.MethodGet, "Accept-Encoding", "gzip"))
+	head := httptest.NewRecorder()
+	h.ServeHTTP(head, compressRequest(http.MethodHead, "Accept-Encoding", "gzip"))
+
+	for _, name := range []string{"Content-Encoding", "ETag", "Content-Length", "Vary"} {
+		if g, h := get.Header().Get(name), head.Header().Get(name); g != h {
+			t.Errorf("%s: GET %q, HEAD %q", name, g, h)
+		}
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD wrote a %d byte body", head.Body.Len())
+	}
+}
+
+func TestCompressRangeOverFileServer(t *testing.T) {
+	// A large compressible file, so only the Range keeps it from being gzipped
+	data := []byte(strings.Repeat("0123456789", 5000))
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "valve.data"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]http.Handler{
+		"DirFS": http.FileServer(http.FS(os.DirFS(dir))),
+		"MapFS": http.FileServer(http.FS(fstest.MapFS{"valve.data": {Data: data}})),
+	}
+
+	for name, backend := range backends {
+		h := Compress(1024, backend)
+		for _, acceptEncoding := range []string{"", "gzip"} {
+			r := compressRequest(http.MethodGet, "Range", "bytes=0-99", "Accept-Encoding", acceptEncoding)
+			r.URL.Path = "/valve.data"
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusPartialContent {
+				t.Fatalf("%s, Accept-Encoding %q: status %d, want 206", name, acceptEncoding, w.Code)
+			}
+			if cr := w.Header().Get("Content-Range"); cr != "bytes 0-99/50000" {
+				t.Errorf("%s, Accept-Encoding %q: Content-Range = %q, want bytes 0-99/50000", name, acceptEncoding, cr)
+			}
+			if ce := w.Header().Get("Content-Encoding"); ce != "" {
+				t.Errorf("%s, Accept-Encoding %q: Content-Encoding = %q, want none", name, acceptEncoding, ce)
+			}
+			if !bytes.Equal(w.Body.Bytes(), data[:100]) {
+				t.Errorf("%s, Accept-Encoding %q: body = %q, want the first 100 bytes", name, acceptEncoding, w.Body.Bytes())
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LimitConcurrency answers 503 with a Retry-After of retryAfter once max
+// requests are in flight. Requests for which skip returns true are neither
+// counted nor turned away. A max of zero or less disables the limit.
+func LimitConcurrency(max int, retryAfter time.Duration, skip func(*http.Request) bool, next http.Handler) http.Handler {
+	if max <= 0 {
+		return next
+	}
+	sem := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			WriteJSONError(w, http.StatusServiceUnavailable, "server_busy", "Too many concurrent requests")
+		}
+	})
+}
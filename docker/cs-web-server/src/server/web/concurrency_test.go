@@ -0,0 +1,67 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	const max = 3
+	started := make(chan struct{})
+	release := make(chan struct{})
+	held := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hold" {
+			started <- struct{}{}
+			<-release
+		}
+	})
+	skip := func(r *http.Request) bool { return r.URL.Path == "/healthz" }
+	h := LimitConcurrency(max, 5*time.Second, skip, held)
+
+	var wg sync.WaitGroup
+	for range max {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hold", nil))
+		}()
+		<-started
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("request %d = %d, want 503", max+1, w.Code)
+	}
+	if ra := w.Header().Get("Retry-After"); ra != "5" {
+		t.Errorf("Retry-After = %q, want 5", ra)
+	}
+	if !strings.Contains(w.Body.String(), `"server_busy"`) {
+		t.Errorf("body = %q, want the server_busy code", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("skipped request while saturated = %d, want 200", w.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("request after the held ones finished = %d, want 200", w.Code)
+	}
+}
+
+func TestLimitConcurrencyDisabled(t *testing.T) {
+	next := plainHandler{}
+	if h := LimitConcurrency(0, time.Second, nil, next); h != http.Handler(next) {
+		t.Error("a max of 0 still wraps the handler")
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ConfigPayload is a JSON document serialized once, plain and gzipped, with
+// an ETag for each encoding. It is never modified, a reload swaps in a new
+// one whole.
+type ConfigPayload struct {
+	json     []byte
+	etag     string
+	gzip     []byte
+	gzipETag string
+}
+
+// NewConfigPayload hashes and compresses the serialized document data
+func NewConfigPayload(data []byte) *ConfigPayload {
+	sum := sha256.Sum256(data)
+	etag := hex.EncodeToString(sum[:16])
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data) //nolint
+	gz.Close()     //nolint
+
+	return &ConfigPayload{
+		json:     data,
+		etag:     `"` + etag + `"`,
+		gzip:     buf.Bytes(),
+		gzipETag: `"` + etag + `-gzip"`,
+	}
+}
+
+// ServeHTTP writes the document, gzipped when the client accepts it, or
+// answers 304 when If-None-Match names the ETag of that encoding
+func (p *ConfigPayload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, etag := p.json, p.etag
+	gzipped := AcceptsGzip(r.Header.Get("Accept-Encoding"))
+	if gzipped {
+		body, etag = p.gzip, p.gzipETag
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "max-age=60")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	w.Write(body) //nolint
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison the header calls for
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
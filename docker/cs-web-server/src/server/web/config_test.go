@@ -0,0 +1,122 @@
+package web
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const configJSON = `{"game_dir":"cstrike","arguments":["-console"]}`
+
+func serveConfig(p *ConfigPayload, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/config", nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	return w
+}
+
+func TestConfigPayloadConditionalGet(t *testing.T) {
+	p := NewConfigPayload([]byte(configJSON))
+	etag := serveConfig(p).Header().Get("ETag")
+	if len(etag) < 3 || etag[0] != '"' {
+		t.Fatalf("ETag = %q, want a strong tag", etag)
+	}
+
+	tests := []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{etag, http.StatusNotModified},
+		{"W/" + etag, http.StatusNotModified},
+		{`"other", ` + etag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{`"other"`, http.StatusOK},
+		{`W/"other"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := serveConfig(p, "If-None-Match", tt.ifNoneMatch)
+		if w.Code != tt.want {
+			t.Errorf("If-None-Match %s = %d, want %d", tt.ifNoneMatch, w.Code, tt.want)
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("If-None-Match %s: ETag = %q, want %q", tt.ifNoneMatch, got, etag)
+		}
+		if tt.want == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: 304 has a body", tt.ifNoneMatch)
+		}
+		if tt.want == http.StatusOK && w.Body.String() != configJSON {
+			t.Errorf("If-None-Match %s: body = %q, want the config", tt.ifNoneMatch, w.Body.String())
+		}
+	}
+	if cc := serveConfig(p).Header().Get("Cache-Control"); cc != "max-age=60" {
+		t.Errorf("Cache-Control = %q, want max-age=60", cc)
+	}
+}
+
+func TestConfigPayloadReloadChangesETag(t *testing.T) {
+	old := serveConfig(NewConfigPayload([]byte(configJSON))).Header().Get("ETag")
+	reloaded := NewConfigPayload([]byte(`{"game_dir":"valve"}`))
+	if etag := serveConfig(reloaded).Header().Get("ETag"); etag == old {
+		t.Fatalf("reloaded config kept ETag %s", etag)
+	}
+	if w := serveConfig(reloaded, "If-None-Match", old); w.Code != http.StatusOK {
+		t.Errorf("ETag of the previous config = %d, want 200", w.Code)
+	}
+}
+
+func TestConfigPayloadEncodings(t *testing.T) {
+	p := NewConfigPayload([]byte(configJSON))
+	identity := serveConfig(p)
+	gzipped := serveConfig(p, "Accept-Encoding", "gzip, deflate")
+
+	for name, w := range map[string]*httptest.ResponseRecorder{"identity": identity, "gzip": gzipped} {
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q, want application/json", name, ct)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q, want Accept-Encoding", name, vary)
+		}
+	}
+
+	if ce := identity.Header().Get("Content-Encoding"); ce != "" {
+		t.Errorf("identity: Content-Encoding = %q, want none", ce)
+	}
+	if identity.Body.String() != configJSON {
+		t.Errorf("identity: body = %q, want the config", identity.Body.String())
+	}
+
+	if ce := gzipped.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("gzip: Content-Encoding = %q, want gzip", ce)
+	}
+	etag := identity.Header().Get("ETag")
+	if want := etag[:len(etag)-1] + `-gzip"`; gzipped.Header().Get("ETag") != want {
+		t.Errorf("gzip: ETag = %q, want %q", gzipped.Header().Get("ETag"), want)
+	}
+	zr, err := gzip.NewReader(gzipped.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != configJSON {
+		t.Errorf("gzip: body decompresses to %q, want the config", body)
+	}
+
+	// Each encoding only revalidates its own ETag
+	if w := serveConfig(p, "Accept-Encoding", "gzip", "If-None-Match", etag); w.Code != http.StatusOK {
+		t.Errorf("gzip with the identity ETag = %d, want 200", w.Code)
+	}
+	if w := serveConfig(p, "If-None-Match", gzipped.Header().Get("ETag")); w.Code != http.StatusOK {
+		t.Errorf("identity with the gzip ETag = %d, want 200", w.Code)
+	}
+	if w := serveConfig(p, "Accept-Encoding", "gzip", "If-None-Match", gzipped.Header().Get("ETag")); w.Code != http.StatusNotModified {
+		t.Errorf("gzip with its own ETag = %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// CORSPolicy decides which cross-origin pages may call the JSON API and open
+// WebSockets. The zero value allows no cross-origin caller.
+type CORSPolicy struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	credentials bool
+}
+
+// NewCORSPolicy builds a policy from exact origins, "*" allows any origin.
+// Credentials can only be allowed for exact origins: a wildcard that hands
+// out credentials would let every site act as the logged-in user.
+func NewCORSPolicy(origins []string, credentials bool) (*CORSPolicy, error) {
+	p := &CORSPolicy{origins: make(map[string]bool), credentials: credentials}
+	for _, origin := range origins {
+		if origin == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		p.origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	if p.anyOrigin && credentials {
+		return nil, errors.New("credentials can't be allowed for the * origin, list the origins instead")
+	}
+	return p, nil
+}
+
+// Enabled reports whether any cross-origin caller is allowed at all
+func (p *CORSPolicy) Enabled() bool {
+	return p.anyOrigin || len(p.origins) > 0
+}
+
+// Allowed reports whether origin may make cross-origin requests
+func (p *CORSPolicy) Allowed(origin string) bool {
+	return p.anyOrigin || p.origins[origin]
+}
+
+// sameOrigin reports whether the Origin header points at the host serving r
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// CheckOrigin lets WebSocket upgrades through for clients that send no
+// Origin, for same-origin pages and for origins allowed by the policy
+func (p *CORSPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || sameOrigin(origin, r) || p.Allowed(origin)
+}
+
+// setHeaders answers "*" when any origin is allowed. Listed origins are
+// echoed back, so that credentialed requests keep working for them.
+func (p *CORSPolicy) setHeaders(w http.ResponseWriter, origin string) {
+	if p.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Handler rejects cross-origin requests from origins outside the policy
+// with 403 and adds CORS headers for the allowed ones
+func (p *CORSPolicy) Handler(next http.Handler) http.Handler {
+	if !p.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin != "" && !sameOrigin(origin, r) {
+			if !p.Allowed(origin) {
+				WriteJSONError(w, http.StatusForbidden, "origin_not_allowed", "Origin not allowed")
+				return
+			}
+			p.setHeaders(w, origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Preflight answers OPTIONS requests for a route accepting the given methods.
+// Only those methods are advertised, and preflights asking for any other
+// method are refused.
+func (p *CORSPolicy) Preflight(methods ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		origin := r.Header.Get("Origin")
+		if origin == "" || !p.Allowed(origin) {
+			WriteJSONError(w, http.StatusForbidden, "origin_not_allowed", "Origin not allowed")
+			return
+		}
+		if m := r.Header.Get("Access-Control-Request-Method"); m != "" && !slices.Contains(methods, m) {
+			w.Header().Set("Allow", strings.Join(append([]string{http.MethodOptions}, methods...), ", "))
+			WriteJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method "+m+" not allowed")
+			return
+		}
+		p.setHeaders(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
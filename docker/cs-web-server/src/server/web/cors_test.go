@@ -0,0 +1,138 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func mustCORSPolicy(t *testing.T, origins []string, credentials bool) *CORSPolicy {
+	t.Helper()
+	p, err := NewCORSPolicy(origins, credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func corsRequest(method, origin string) *http.Request {
+	r := httptest.NewRequest(method, "http://game.example/config", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	return r
+}
+
+func TestNewCORSPolicyRefusesWildcardCredentials(t *testing.T) {
+	if _, err := NewCORSPolicy([]string{"*"}, true); err == nil {
+		t.Error("NewCORSPolicy(*, credentials) succeeded, want an error")
+	}
+	if _, err := NewCORSPolicy([]string{"https://play.example"}, true); err != nil {
+		t.Errorf("NewCORSPolicy(exact origin, credentials) = %v", err)
+	}
+}
+
+func TestCORSWildcardSendsLiteralStar(t *testing.T) {
+	p := mustCORSPolicy(t, []string{"*"}, false)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for name, h := range map[string]http.Handler{
+		"GET":       p.Handler(ok),
+		"preflight": p.Preflight(http.MethodGet),
+	} {
+		method := http.MethodGet
+		if name == "preflight" {
+			method = http.MethodOptions
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, corsRequest(method, "http://evil.example"))
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want *", name, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("%s: Access-Control-Allow-Credentials = %q, want none", name, got)
+		}
+	}
+}
+
+func TestCORSListedOrigins(t *testing.T) {
+	p := mustCORSPolicy(t, []string{"https://play.example/"}, true)
+	h := p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, corsRequest(http.MethodGet, "https://play.example"))
+	if w.Code != http.StatusOK {
+		t.Errorf("listed origin: status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://play.example" {
+		t.Errorf("listed origin: Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("listed origin: Access-Control-Allow-Credentials = %q, want true", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, corsRequest(http.MethodGet, "http://evil.example"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("other origin: status = %d, want 403", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("other origin: Access-Control-Allow-Origin = %q, want none", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, corsRequest(http.MethodGet, "http://GAME.example"))
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("same origin: got %d with CORS headers %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSPreflightMethods(t *testing.T) {
+	p := mustCORSPolicy(t, []string{"https://play.example"}, false)
+	h := p.Preflight(http.MethodGet)
+
+	r := corsRequest(http.MethodOptions, "https://play.example")
+	r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("GET preflight: status = %d, want 204", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET" {
+		t.Errorf("GET preflight: Access-Control-Allow-Methods = %q, want GET", got)
+	}
+
+	r = corsRequest(http.MethodOptions, "https://play.example")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST preflight: status = %d, want 405", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("POST preflight: Access-Control-Allow-Methods = %q, want none", got)
+	}
+}
+
+func TestCORSCheckOrigin(t *testing.T) {
+	p := mustCORSPolicy(t, []string{"https://play.example"}, false)
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://game.example", true},
+		{"https://play.example", true},
+		{"http://evil.example", false},
+	}
+	for _, tt := range tests {
+		if got := p.CheckOrigin(corsRequest(http.MethodGet, tt.origin)); got != tt.want {
+			t.Errorf("CheckOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	var none CORSPolicy
+	if none.Enabled() || none.CheckOrigin(corsRequest(http.MethodGet, "https://play.example")) {
+		t.Error("zero CORSPolicy allows cross-origin callers")
+	}
+}
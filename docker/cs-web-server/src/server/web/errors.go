@@ -0,0 +1,27 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteJSONError answers with the error envelope API clients parse:
+//
+//	{"error":{"code":"rate_limited","message":"...","requestId":"..."}}
+//
+// The request ID is taken from the X-Request-ID response header.
+func WriteJSONError(w http.ResponseWriter, status int, code, msg string) {
+	type apiError struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"requestId,omitempty"`
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Del("Content-Length")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct { //nolint
+		Error apiError `json:"error"`
+	}{apiError{code, msg, h.Get("X-Request-ID")}})
+}
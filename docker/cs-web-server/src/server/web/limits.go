@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MessageLimiter counts inbound messages of one connection in one-second
+// windows. It is not safe for concurrent use, each read loop owns one.
+type MessageLimiter struct {
+	limit  int
+	window time.Time
+	count  int
+}
+
+// NewMessageLimiter allows perSecond messages per one-second window
+func NewMessageLimiter(perSecond int) *MessageLimiter {
+	return &MessageLimiter{limit: perSecond}
+}
+
+// Allow reports whether one more message fits into the current window. A
+// limit of zero or less allows everything.
+func (l *MessageLimiter) Allow(now time.Time) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	if now.Sub(l.window) >= time.Second {
+		l.window = now
+		l.count = 0
+	}
+	l.count++
+	return l.count <= l.limit
+}
+
+// Connections counts open connections against a total cap. The zero value
+// has no open connections.
+type Connections struct {
+	open atomic.Int64
+}
+
+// Acquire takes one of max slots. Callers that get true must give it back
+// with Release.
+func (c *Connections) Acquire(max int) bool {
+	if c.open.Add(1) > int64(max) {
+		c.open.Add(-1)
+		return false
+	}
+	return true
+}
+
+// Release gives back a slot taken by Acquire
+func (c *Connections) Release() {
+	c.open.Add(-1)
+}
+
+// Open returns the number of slots currently taken
+func (c *Connections) Open() int64 {
+	return c.open.Load()
+}
+
+// IPConnections counts open connections per client address
+type IPConnections struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewIPConnections returns a counter with no open connections
+func NewIPConnections() *IPConnections {
+	return &IPConnections{counts: make(map[string]int)}
+}
+
+// Acquire takes a slot for ip unless it already holds max of them, in which
+// case it returns the current count and false. A max of zero or less
+// disables the cap. Peers without an IP, such as those of a unix socket
+// listener, are never capped since they would all share one key.
+func (c *IPConnections) Acquire(ip string, max int) (int, bool) {
+	if max <= 0 {
+		return 0, true
+	}
+	if _, err := netip.ParseAddr(ip); err != nil {
+		return 0, true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.counts[ip]
+	if n >= max {
+		return n, false
+	}
+	c.counts[ip] = n + 1
+	return n + 1, true
+}
+
+// Release gives back a slot taken by Acquire
+func (c *IPConnections) Release(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[ip] <= 1 {
+		delete(c.counts, ip)
+		return
+	}
+	c.counts[ip]--
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMessageLimiter(t *testing.T) {
+	start := time.Unix(1000, 0)
+	l := NewMessageLimiter(3)
+	for i := range 3 {
+		if !l.Allow(start.Add(time.Duration(i) * 100 * time.Millisecond)) {
+			t.Fatalf("message %d refused within the limit", i+1)
+		}
+	}
+	if l.Allow(start.Add(900 * time.Millisecond)) {
+		t.Error("4th message within one second allowed")
+	}
+	if !l.Allow(start.Add(time.Second)) {
+		t.Error("message in the next window refused")
+	}
+}
+
+func TestMessageLimiterDisabled(t *testing.T) {
+	now := time.Unix(1000, 0)
+	for _, limit := range []int{0, -1} {
+		l := NewMessageLimiter(limit)
+		for i := range 1000 {
+			if !l.Allow(now) {
+				t.Fatalf("limit %d: message %d refused", limit, i+1)
+			}
+		}
+	}
+}
+
+func TestConnections(t *testing.T) {
+	var c Connections
+	for i := range 3 {
+		if !c.Acquire(3) {
+			t.Fatalf("connection %d refused within the cap", i+1)
+		}
+	}
+	if c.Acquire(3) {
+		t.Error("4th connection accepted over a cap of 3")
+	}
+	if n := c.Open(); n != 3 {
+		t.Errorf("Open = %d after a refusal, want 3", n)
+	}
+
+	c.Release()
+	if !c.Acquire(3) {
+		t.Error("connection refused after a Release")
+	}
+}
+
+func TestConnectionsConcurrent(t *testing.T) {
+	var c Connections
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.Acquire(10) {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := accepted.Load(); n != 10 {
+		t.Errorf("%d connections accepted concurrently, want 10", n)
+	}
+}
+
+func TestIPConnections(t *testing.T) {
+	c := NewIPConnections()
+	for i := range 2 {
+		if n, ok := c.Acquire("192.0.2.1", 2); !ok || n != i+1 {
+			t.Fatalf("Acquire %d = %d, %v, want %d, true", i+1, n, ok, i+1)
+		}
+	}
+	if n, ok := c.Acquire("192.0.2.1", 2); ok || n != 2 {
+		t.Errorf("Acquire over the cap = %d, %v, want 2, false", n, ok)
+	}
+	if _, ok := c.Acquire("2001:db8::1", 2); !ok {
+		t.Error("another address shares the cap")
+	}
+
+	c.Release("192.0.2.1")
+	if _, ok := c.Acquire("192.0.2.1", 2); !ok {
+		t.Error("Acquire after Release refused")
+	}
+	c.Release("192.0.2.1")
+	c.Release("192.0.2.1")
+	if len(c.counts) != 1 {
+		t.Errorf("counts = %v, want only 2001:db8::1 left", c.counts)
+	}
+}
+
+func TestIPConnectionsUncapped(t *testing.T) {
+	c := NewIPConnections()
+	for _, tt := range []struct {
+		ip  string
+		max int
+	}{
+		{"192.0.2.1", 0},
+		{"", 1},
+		{"@", 1},
+	} {
+		for i := range 10 {
+			if _, ok := c.Acquire(tt.ip, tt.max); !ok {
+				t.Fatalf("Acquire(%q, %d) #%d refused", tt.ip, tt.max, i+1)
+			}
+		}
+	}
+	if len(c.counts) != 0 {
+		t.Errorf("uncapped peers were counted: %v", c.counts)
+	}
+}
@@ -0,0 +1,21 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Maintenance answers 503 with a Retry-After of retryAfter while on is set.
+// Requests for which skip returns true are served either way.
+func Maintenance(on *atomic.Bool, retryAfter time.Duration, skip func(*http.Request) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !on.Load() || skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		WriteJSONError(w, http.StatusServiceUnavailable, "maintenance", "Server is under maintenance")
+	})
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	var on atomic.Bool
+	skip := func(r *http.Request) bool { return r.URL.Path == "/healthz" }
+	h := Maintenance(&on, time.Minute, skip, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if w := get("/config"); w.Code != http.StatusOK {
+		t.Fatalf("off: GET /config = %d, want 200", w.Code)
+	}
+
+	on.Store(true)
+	for _, target := range []string{"/config", "/", "/readyz"} {
+		w := get(target)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("on: GET %s = %d, want 503", target, w.Code)
+		}
+		if ra := w.Header().Get("Retry-After"); ra != "60" {
+			t.Errorf("on: GET %s Retry-After = %q, want 60", target, ra)
+		}
+		if !strings.Contains(w.Body.String(), `"maintenance"`) {
+			t.Errorf("on: GET %s body = %q, want the maintenance code", target, w.Body.String())
+		}
+	}
+	if w := get("/healthz"); w.Code != http.StatusOK {
+		t.Errorf("on: GET /healthz = %d, want 200", w.Code)
+	}
+
+	on.Store(false)
+	if w := get("/config"); w.Code != http.StatusOK || w.Header().Get("Retry-After") != "" {
+		t.Errorf("off again: GET /config = %d Retry-After %q, want 200 without it", w.Code, w.Header().Get("Retry-After"))
+	}
+}
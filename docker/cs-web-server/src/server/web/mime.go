@@ -0,0 +1,27 @@
+package web
+
+import (
+	"fmt"
+	"mime"
+)
+
+// staticTypes pins the Content-Type of the game files. Go only knows some of
+// them and otherwise asks the system mime.types, which slim images lack.
+// Browsers need application/wasm to compile modules while streaming.
+var staticTypes = map[string]string{
+	".wasm": "application/wasm",
+	".data": "application/octet-stream",
+	".mem":  "application/octet-stream",
+	".pk3":  "application/zip",
+	".zip":  "application/zip",
+}
+
+// RegisterStaticTypes makes http.FileServer use staticTypes
+func RegisterStaticTypes() error {
+	for ext, typ := range staticTypes {
+		if err := mime.AddExtensionType(ext, typ); err != nil {
+			return fmt.Errorf("register %s: %w", ext, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticTypes(t *testing.T) {
+	if err := RegisterStaticTypes(); err != nil {
+		t.Fatal(err)
+	}
+	// Plain text content, so a sniffed type would come out as text/plain
+	content := []byte("webxash game file")
+	root := fstest.MapFS{
+		"xash.wasm":      {Data: content},
+		"valve.data":     {Data: content},
+		"xash.mem":       {Data: content},
+		"cstrike/de.pk3": {Data: content},
+		"extras.zip":     {Data: content},
+	}
+	h := http.FileServer(http.FS(root))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/xash.wasm", "application/wasm"},
+		{"/valve.data", "application/octet-stream"},
+		{"/xash.mem", "application/octet-stream"},
+		{"/cstrike/de.pk3", "application/zip"},
+		{"/extras.zip", "application/zip"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, want 200", tt.path, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != tt.want {
+			t.Errorf("GET %s Content-Type = %q, want %q", tt.path, ct, tt.want)
+		}
+	}
+}
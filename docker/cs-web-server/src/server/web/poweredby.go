@@ -0,0 +1,20 @@
+package web
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// XPoweredByFromEnv reads the X-Powered-By value from DISABLE_X_POWERED_BY
+// and X_POWERED_BY_VALUE, falling back to def. Disabling it or setting a
+// blank value returns "", which callers take as omitting the header.
+func XPoweredByFromEnv(def string) string {
+	if disable, _ := strconv.ParseBool(os.Getenv("DISABLE_X_POWERED_BY")); disable {
+		return ""
+	}
+	if value, has := os.LookupEnv("X_POWERED_BY_VALUE"); has {
+		return strings.TrimSpace(value)
+	}
+	return def
+}
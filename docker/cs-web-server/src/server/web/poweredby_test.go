@@ -0,0 +1,39 @@
+package web
+
+import (
+	"os"
+	"testing"
+)
+
+func TestXPoweredByFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"default", nil, "yohimik"},
+		{"custom", map[string]string{"X_POWERED_BY_VALUE": "CS 1.6 Web Server"}, "CS 1.6 Web Server"},
+		{"custom trimmed", map[string]string{"X_POWERED_BY_VALUE": "  CS 1.6  "}, "CS 1.6"},
+		{"blank value", map[string]string{"X_POWERED_BY_VALUE": " "}, ""},
+		{"disabled", map[string]string{"DISABLE_X_POWERED_BY": "1"}, ""},
+		{"disabled with value", map[string]string{"DISABLE_X_POWERED_BY": "true", "X_POWERED_BY_VALUE": "CS 1.6"}, ""},
+		{"not disabled", map[string]string{"DISABLE_X_POWERED_BY": "false", "X_POWERED_BY_VALUE": "CS 1.6"}, "CS 1.6"},
+		{"unparsable disable", map[string]string{"DISABLE_X_POWERED_BY": "yes"}, "yohimik"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DISABLE_X_POWERED_BY", "X_POWERED_BY_VALUE"} {
+				// Setenv restores the variable after the test, Unsetenv
+				// then tells an unset variable from a blank one
+				t.Setenv(key, "")
+				os.Unsetenv(key) //nolint
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if got := XPoweredByFromEnv("yohimik"); got != tt.want {
+				t.Errorf("XPoweredByFromEnv = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
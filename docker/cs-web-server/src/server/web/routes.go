@@ -0,0 +1,14 @@
+package web
+
+import "strings"
+
+// IsAPIPath reports whether p is one of the API route paths or lives below
+// one of them. Everything else is left to the static files.
+func IsAPIPath(routes []string, p string) bool {
+	for _, route := range routes {
+		if p == route || strings.HasPrefix(p, route+"/") {
+			return true
+		}
+	}
+	return false
+}
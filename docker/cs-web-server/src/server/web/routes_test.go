@@ -0,0 +1,26 @@
+package web
+
+import "testing"
+
+func TestIsAPIPath(t *testing.T) {
+	// The paths of apiRoutes in the main package
+	routes := []string{"/websocket", "/config", "/metrics", "/healthz", "/readyz", "/version"}
+	for _, route := range routes {
+		for _, p := range []string{route, route + "/", route + "/x"} {
+			if !IsAPIPath(routes, p) {
+				t.Errorf("IsAPIPath(%q) = false, want true", p)
+			}
+		}
+		if p := route + "x"; IsAPIPath(routes, p) {
+			t.Errorf("IsAPIPath(%q) = true, want false", p)
+		}
+	}
+	for _, p := range []string{"/", "/index.html", "/xash.wasm", "/cstrike/maps/de_dust2.bsp", "/404.html", "/configs/server.cfg"} {
+		if IsAPIPath(routes, p) {
+			t.Errorf("IsAPIPath(%q) = true for a static path", p)
+		}
+	}
+	if IsAPIPath(nil, "/config") {
+		t.Error("IsAPIPath without routes = true")
+	}
+}
@@ -0,0 +1,36 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Drain says goodbye to the connections of hub, shuts the servers down and
+// waits for the handlers counted by hijacked, all within ctx. Shutdown only
+// waits for regular requests, hijacked handlers such as WebSocket sessions
+// have to be counted separately.
+func Drain(ctx context.Context, hub *Hub, hijacked *sync.WaitGroup, servers ...*http.Server) error {
+	hub.CloseAll("server shutting down")
+
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shut down http server: %w", err))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		hijacked.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("wait for hijacked connections: %w", ctx.Err()))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrainCompletesInFlightRequest(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done") //nolint
+	}))
+	t.Cleanup(srv.Close)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	res := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			res <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		res <- result{resp.StatusCode, string(body), err}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Drain(ctx, NewHub(), new(sync.WaitGroup), srv.Config); err != nil {
+		t.Fatalf("Drain = %v", err)
+	}
+
+	select {
+	case r := <-res:
+		if r.err != nil || r.status != http.StatusOK || r.body != "done" {
+			t.Errorf("in-flight request = %d %q %v, want 200 %q", r.status, r.body, r.err, "done")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("in-flight request never completed")
+	}
+
+	if _, err := http.Get(srv.URL); err == nil {
+		t.Error("request after Drain was served")
+	}
+}
+
+func TestDrainWaitsForHijackedHandlers(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	var hijacked sync.WaitGroup
+	hijacked.Add(1)
+	time.AfterFunc(100*time.Millisecond, hijacked.Done)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Drain(ctx, NewHub(), &hijacked, srv.Config); err != nil {
+		t.Fatalf("Drain = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Drain returned after %v, before the hijacked handler finished", elapsed)
+	}
+}
+
+func TestDrainGracePeriod(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	var hijacked sync.WaitGroup
+	hijacked.Add(1)
+	defer hijacked.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Drain(ctx, NewHub(), &hijacked, srv.Config); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain past the grace period = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,26 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// SlowRequests calls report for every request that takes longer than
+// threshold to serve. Requests for which skip returns true are not timed. A
+// threshold of zero or less disables it.
+func SlowRequests(threshold time.Duration, skip func(*http.Request) bool, report func(r *http.Request, elapsed time.Duration), next http.Handler) http.Handler {
+	if threshold <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		if elapsed := time.Since(start); elapsed > threshold {
+			report(r, elapsed)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowRequests(t *testing.T) {
+	const threshold = 10 * time.Millisecond
+	sleepy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("sleep") {
+			time.Sleep(2 * threshold)
+		}
+	})
+	skip := func(r *http.Request) bool { return r.URL.Path == "/websocket" }
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"/config?sleep", true},
+		{"/config", false},
+		{"/websocket?sleep", false},
+	}
+	for _, tt := range tests {
+		var reported time.Duration
+		h := SlowRequests(threshold, skip, func(r *http.Request, elapsed time.Duration) {
+			reported = elapsed
+		}, sleepy)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if got := reported != 0; got != tt.want {
+			t.Errorf("%s: reported = %v, want %v", tt.target, got, tt.want)
+		}
+		if tt.want && reported <= threshold {
+			t.Errorf("%s: reported %v, want more than %v", tt.target, reported, threshold)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package web holds the HTTP building blocks of the server that don't touch
+// the engine. The main package links against libxash through cgo, so its test
+// binary can't be built without the engine libraries. Code here has no such
+// dependency and is tested with plain go test.
+package web
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// EscapesRoot reports whether a decoded request path contains a ".."
+// segment, using either slash as a separator
+func EscapesRoot(p string) bool {
+	segments := strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' })
+	for _, segment := range segments {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// PathGuard answers with notFound for paths that try to climb out of the
+// static root instead of letting them resolve to something inside it
+func PathGuard(notFound http.HandlerFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if EscapesRoot(r.URL.Path) {
+			notFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StaticName converts a request path into a name inside a static root
+func StaticName(urlPath string) string {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// NotFound answers 404 with the JSON error envelope below the paths isAPI
+// accepts, with 404.html from root when it has one, and with the plain
+// default otherwise
+func NotFound(root fs.FS, isAPI func(string) bool, w http.ResponseWriter, r *http.Request) {
+	if isAPI(r.URL.Path) {
+		WriteJSONError(w, http.StatusNotFound, "not_found", "No such API route")
+		return
+	}
+	page, err := fs.ReadFile(root, "404.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(page) //nolint
+}
+
+// NotFoundPage answers requests for files missing from root through
+// NotFound instead of the bare 404 of http.FileServer
+func NotFoundPage(root fs.FS, isAPI func(string) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := fs.Stat(root, StaticName(r.URL.Path)); errors.Is(err, fs.ErrNotExist) {
+			NotFound(root, isAPI, w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// missingIndexPage explains a deploy without index.html instead of the
+// directory listing http.FileServer would show for /
+const missingIndexPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Game files missing</title></head>
+<body>
+<h1>Game files missing</h1>
+<p>The web root has no index.html. Check that the client build was copied into WEB_ROOT or embedded into the server binary.</p>
+</body>
+</html>
+`
+
+// HasIndex reports whether root contains index.html
+func HasIndex(root fs.FS) bool {
+	info, err := fs.Stat(root, "index.html")
+	return err == nil && !info.IsDir()
+}
+
+// IndexFallback answers / with missingIndexPage and a 500 while root has no
+// index.html. Other missing files still get the regular 404.
+func IndexFallback(root fs.FS, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" || HasIndex(root) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, missingIndexPage) //nolint
+	})
+}
@@ -0,0 +1,204 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEscapesRoot(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", false},
+		{"/game.data", false},
+		{"/maps/de_dust.bsp", false},
+		{"/..", true},
+		{"/../etc/passwd", true},
+		{"/maps/../../etc/passwd", true},
+		{`/..\etc\passwd`, true},
+		{`/maps\..\..\secret`, true},
+		{"/..foo/bar", false},
+		{"/%2e%2e/secret", false},
+	}
+	for _, tt := range tests {
+		if got := EscapesRoot(tt.path); got != tt.want {
+			t.Errorf("EscapesRoot(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPathGuardTraversal(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "public")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const secret = "top secret outside the web root"
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte(secret), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "game.data"), []byte("game"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]http.Handler{
+		"FileServer": http.FileServer(http.FS(os.DirFS(root))),
+		// naive trusts the path, so only the guard keeps it inside root
+		"naive": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := os.ReadFile(filepath.Join(root, r.URL.Path))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data) //nolint
+		}),
+	}
+
+	targets := []string{
+		"/../secret.txt",
+		"/..%2fsecret.txt",
+		"/..%2f..%2fetc%2fpasswd",
+		"/%2e%2e/secret.txt",
+		"/%2e%2e%2fsecret.txt",
+		"/%252e%252e/secret.txt",
+		"/%252e%252e%252fsecret.txt",
+		"/..%255csecret.txt",
+		`/..\secret.txt`,
+		"/..%5csecret.txt",
+		"/..%5c..%5cetc%5cpasswd",
+	}
+	for name, backend := range backends {
+		h := PathGuard(http.NotFound, backend)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/game.data", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "game" {
+			t.Fatalf("%s: GET /game.data = %d %q, want 200 %q", name, w.Code, w.Body.String(), "game")
+		}
+
+		for _, target := range targets {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s: GET %s = %d, want 404", name, target, w.Code)
+			}
+			if strings.Contains(w.Body.String(), secret) {
+				t.Errorf("%s: GET %s served a file outside the root", name, target)
+			}
+		}
+	}
+}
+
+func TestPathGuardBeforeServeMux(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "maps"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServer(http.FS(os.DirFS(root))))
+	h := PathGuard(http.NotFound, mux)
+
+	// The mux cleans these paths with a redirect into the root, so the guard
+	// only sees them when it runs first
+	for _, target := range []string{"/../secret.txt", "/maps/../../etc/passwd", "/maps/../secret.txt"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("unguarded GET %s = %d, want the mux redirect", target, w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d Location %q, want 404", target, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestIndexFallback(t *testing.T) {
+	empty := fstest.MapFS{}
+	if HasIndex(empty) {
+		t.Fatal("HasIndex of an empty root = true")
+	}
+	h := IndexFallback(empty, http.FileServer(http.FS(empty)))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("GET / = %d, want 500", w.Code)
+	}
+	if w.Body.String() != missingIndexPage {
+		t.Errorf("GET / body = %q, want the built-in page", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("GET / Content-Type = %q, want HTML", ct)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing.js", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /missing.js = %d, want 404", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "Game files missing") {
+		t.Error("GET /missing.js got the missing index page")
+	}
+}
+
+func TestIndexFallbackWithIndex(t *testing.T) {
+	root := fstest.MapFS{"index.html": {Data: []byte("<h1>game</h1>")}}
+	if !HasIndex(root) {
+		t.Fatal("HasIndex = false with index.html present")
+	}
+	w := httptest.NewRecorder()
+	IndexFallback(root, http.FileServer(http.FS(root))).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "<h1>game</h1>" {
+		t.Errorf("GET / = %d %q, want the index page", w.Code, w.Body.String())
+	}
+}
+
+func TestNotFoundPage(t *testing.T) {
+	isAPI := func(p string) bool { return p == "/config" || strings.HasPrefix(p, "/config/") }
+	const page = "<h1>No such map</h1>"
+	withPage := fstest.MapFS{
+		"game.txt": {Data: []byte("game")},
+		"404.html": {Data: []byte(page)},
+	}
+	withoutPage := fstest.MapFS{"game.txt": {Data: []byte("game")}}
+
+	tests := []struct {
+		name        string
+		root        fstest.MapFS
+		target      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"existing file", withPage, "/game.txt", http.StatusOK, "text/plain; charset=utf-8", "game"},
+		{"html", withPage, "/maps/missing.bsp", http.StatusNotFound, "text/html; charset=utf-8", page},
+		{"fallback", withoutPage, "/maps/missing.bsp", http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		{"json", withPage, "/config/missing", http.StatusNotFound, "application/json", `"code":"not_found"`},
+		{"json without page", withoutPage, "/config/missing", http.StatusNotFound, "application/json", `"code":"not_found"`},
+	}
+	for _, tt := range tests {
+		h := NotFoundPage(tt.root, isAPI, http.FileServer(http.FS(tt.root)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: GET %s = %d, want %d", tt.name, tt.target, w.Code, tt.status)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.name, ct, tt.contentType)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: body = %q, want %q", tt.name, w.Body.String(), tt.body)
+		}
+	}
+}
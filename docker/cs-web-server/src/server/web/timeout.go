@@ -0,0 +1,107 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithTimeout gives next a deadline of d through its request context. If the
+// deadline passes before next returns, the client gets a JSON 503 and
+// whatever next writes afterwards is discarded. Meant for short JSON routes,
+// not for /websocket or static downloads. A d of zero or less disables it.
+func WithTimeout(d time.Duration, next http.Handler) http.Handler {
+	if d <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		// Start from the headers set so far so that the handler can add to
+		// them, e.g. Vary, and copying them back neither loses nor repeats any
+		tw := &timeoutWriter{ctx: ctx, header: w.Header().Clone()}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			if tw.expired() {
+				WriteJSONError(w, http.StatusServiceUnavailable, "timeout", "Request timed out")
+				return
+			}
+			for k, v := range tw.header {
+				w.Header()[k] = v
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			w.Write(tw.buf.Bytes()) //nolint
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			WriteJSONError(w, http.StatusServiceUnavailable, "timeout", "Request timed out")
+		}
+	})
+}
+
+// timeoutWriter buffers the response of a handler run by WithTimeout so it
+// can be dropped when the deadline passes first
+type timeoutWriter struct {
+	ctx      context.Context
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+// expired reports whether the deadline has passed, which turns away all later
+// writes. The handler may notice its context is done before WithTimeout
+// does, so the context is checked here and not just the flag. Callers hold mu.
+func (tw *timeoutWriter) expired() bool {
+	if !tw.timedOut && tw.ctx.Err() != nil {
+		tw.timedOut = true
+	}
+	return tw.timedOut
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
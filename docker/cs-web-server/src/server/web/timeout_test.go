@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWithTimeoutExpires(t *testing.T) {
+	lateWrite := make(chan error, 1)
+	handlerErr := make(chan error, 1)
+	h := WithTimeout(20*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		handlerErr <- r.Context().Err()
+		w.Header().Set("X-Late", "1")
+		_, err := w.Write([]byte("too late"))
+		lateWrite <- err
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error.Code != "timeout" {
+		t.Errorf("body = %q, want a timeout error envelope", w.Body.String())
+	}
+
+	if err := <-handlerErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("handler context error = %v, want DeadlineExceeded", err)
+	}
+	if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("late Write error = %v, want http.ErrHandlerTimeout", err)
+	}
+	if w.Header().Get("X-Late") != "" {
+		t.Error("header set after the timeout reached the response")
+	}
+}
+
+func TestWithTimeoutCopiesResponse(t *testing.T) {
+	h := WithTimeout(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"ok":true}`)) //nolint
+	}))
+
+	w := httptest.NewRecorder()
+	w.Header().Add("Vary", "Origin")
+	w.Header().Set("X-Request-ID", "abc")
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	if w.Code != http.StatusAccepted || w.Body.String() != `{"ok":true}` {
+		t.Fatalf("response = %d %q, want 202 {\"ok\":true}", w.Code, w.Body.String())
+	}
+	if got, want := w.Header().Values("Vary"), []string{"Origin", "Accept-Encoding"}; !slices.Equal(got, want) {
+		t.Errorf("Vary = %q, want %q", got, want)
+	}
+	if got := w.Header().Values("X-Request-ID"); !slices.Equal(got, []string{"abc"}) {
+		t.Errorf("X-Request-ID = %q, want [abc]", got)
+	}
+}
+
+func TestWithTimeoutRepanics(t *testing.T) {
+	h := WithTimeout(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("recovered %v, want boom", p)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/config", nil))
+	t.Error("ServeHTTP returned instead of panicking")
+}
+
+type plainHandler struct{}
+
+func (plainHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func TestWithTimeoutDisabled(t *testing.T) {
+	if _, ok := WithTimeout(0, plainHandler{}).(plainHandler); !ok {
+		t.Error("WithTimeout(0) wrapped the handler")
+	}
+}
@@ -0,0 +1,111 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ControlWriteWait bounds how long a ping or close frame may take to send
+const ControlWriteWait = time.Second
+
+// NegotiableSubprotocol reports whether an upgrade request offers either no
+// subprotocol or at least one of supported
+func NegotiableSubprotocol(r *http.Request, supported []string) bool {
+	offered := websocket.Subprotocols(r)
+	if len(offered) == 0 {
+		return true
+	}
+	for _, p := range offered {
+		if slices.Contains(supported, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpectPongs makes reads fail when no pong arrives within pongTimeout. It
+// touches the reader side of the connection, so it must be called from the
+// goroutine that reads, before the read loop starts.
+func ExpectPongs(c *websocket.Conn, pongTimeout time.Duration) {
+	c.SetReadDeadline(time.Now().Add(pongTimeout)) //nolint
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+}
+
+// KeepAlive pings the peer every pingInterval so that ExpectPongs sees
+// pongs from live peers. It returns once closed is closed.
+func KeepAlive(c *websocket.Conn, pingInterval time.Duration, closed <-chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(ControlWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// Hub is the set of open WebSocket connections, kept so that shutdown can
+// send each of them a close frame instead of dropping them
+type Hub struct {
+	mu      sync.Mutex
+	conns   map[*websocket.Conn]struct{}
+	closing bool
+}
+
+// NewHub returns a hub with no connections
+func NewHub() *Hub {
+	return &Hub{conns: make(map[*websocket.Conn]struct{})}
+}
+
+// Add registers a connection. It returns false once the hub has been closed,
+// in which case the caller should say goodbye and drop the connection.
+func (h *Hub) Add(c *websocket.Conn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return false
+	}
+	h.conns[c] = struct{}{}
+	return true
+}
+
+// Remove unregisters a connection once its handler is done with it
+func (h *Hub) Remove(c *websocket.Conn) {
+	h.mu.Lock()
+	delete(h.conns, c)
+	h.mu.Unlock()
+}
+
+// CloseAll sends a going-away close frame to every registered connection and
+// closes it. Connections added afterwards are refused.
+func (h *Hub) CloseAll(reason string) {
+	h.mu.Lock()
+	h.closing = true
+	conns := make([]*websocket.Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		CloseGoingAway(c, reason)
+	}
+}
+
+// CloseGoingAway tells the peer the server is leaving and closes the
+// connection, which makes the handler's read loop return
+func CloseGoingAway(c *websocket.Conn, reason string) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(ControlWriteWait)) //nolint
+	c.Close()                                                                     //nolint
+}
@@ -0,0 +1,204 @@
+package web
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connect opens a client connection to srv
+func connect(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// dial connects to srv and reads from the connection in the background so
+// control frames get handled
+func dial(t *testing.T, srv *httptest.Server, setup func(*websocket.Conn)) *websocket.Conn {
+	t.Helper()
+	c := connect(t, srv)
+	if setup != nil {
+		setup(c)
+	}
+	go func() {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// keepAliveServer runs the ping/pong keepalive on every connection and
+// reports the error its read loop ends with
+func keepAliveServer(t *testing.T, pingInterval, pongTimeout time.Duration) (*httptest.Server, <-chan error) {
+	readErr := make(chan error, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		closed := make(chan struct{})
+		defer close(closed)
+		ExpectPongs(c, pongTimeout)
+		go KeepAlive(c, pingInterval, closed)
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, readErr
+}
+
+func TestKeepAliveClosesSilentPeer(t *testing.T) {
+	srv, readErr := keepAliveServer(t, 20*time.Millisecond, 100*time.Millisecond)
+	dial(t, srv, func(c *websocket.Conn) {
+		c.SetPingHandler(func(string) error { return nil })
+	})
+
+	select {
+	case err := <-readErr:
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Errorf("read loop ended with %v, want a timeout", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection of a peer that never pongs was kept open")
+	}
+}
+
+func TestKeepAliveKeepsLivePeer(t *testing.T) {
+	srv, readErr := keepAliveServer(t, 20*time.Millisecond, 100*time.Millisecond)
+	dial(t, srv, nil)
+
+	select {
+	case err := <-readErr:
+		t.Fatalf("connection of a ponging peer closed: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+// hubServer registers every connection with hub, saying goodbye right away
+// when the hub refuses it, and reports whether it was added
+func hubServer(t *testing.T, hub *Hub) (*httptest.Server, <-chan bool) {
+	added := make(chan bool, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if !hub.Add(c) {
+			added <- false
+			CloseGoingAway(c, "server shutting down")
+			return
+		}
+		defer hub.Remove(c)
+		added <- true
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, added
+}
+
+// expectGoingAway reads from c until the close frame and checks it
+func expectGoingAway(t *testing.T, c *websocket.Conn, reason string) {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second)) //nolint
+	_, _, err := c.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("read = %v, want a close frame", err)
+	}
+	if closeErr.Code != websocket.CloseGoingAway || closeErr.Text != reason {
+		t.Errorf("close frame = %d %q, want %d %q", closeErr.Code, closeErr.Text, websocket.CloseGoingAway, reason)
+	}
+}
+
+func TestHubCloseAll(t *testing.T) {
+	hub := NewHub()
+	srv, added := hubServer(t, hub)
+	clients := []*websocket.Conn{connect(t, srv), connect(t, srv)}
+	for range clients {
+		if !<-added {
+			t.Fatal("connection refused before shutdown")
+		}
+	}
+
+	hub.CloseAll("server shutting down")
+	for _, c := range clients {
+		expectGoingAway(t, c, "server shutting down")
+	}
+
+	late := connect(t, srv)
+	if <-added {
+		t.Error("connection added after CloseAll")
+	}
+	expectGoingAway(t, late, "server shutting down")
+}
+
+func TestSubprotocolNegotiation(t *testing.T) {
+	supported := []string{"webxash.v1"}
+	upgrader := websocket.Upgrader{Subprotocols: supported}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !NegotiableSubprotocol(r, supported) {
+			WriteJSONError(w, http.StatusBadRequest, "unsupported_subprotocol", "No supported WebSocket subprotocol offered")
+			return
+		}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.Close()
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		offered []string
+		status  int
+		chosen  string
+	}{
+		{[]string{"webxash.v1"}, http.StatusSwitchingProtocols, "webxash.v1"},
+		{[]string{"foo", "webxash.v1"}, http.StatusSwitchingProtocols, "webxash.v1"},
+		{[]string{"foo"}, http.StatusBadRequest, ""},
+		{nil, http.StatusSwitchingProtocols, ""},
+	}
+	for _, tt := range tests {
+		dialer := websocket.Dialer{Subprotocols: tt.offered}
+		c, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+		if c != nil {
+			c.Close()
+		}
+		if resp == nil {
+			t.Fatalf("offering %q: %v", tt.offered, err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("offering %q: status %d, want %d", tt.offered, resp.StatusCode, tt.status)
+		}
+		if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != tt.chosen {
+			t.Errorf("offering %q: chose %q, want %q", tt.offered, got, tt.chosen)
+		}
+	}
+}
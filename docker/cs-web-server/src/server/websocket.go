@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yohimik/webxash3d-fwgs/docker/cs-web-server/src/server/web"
+)
+
+// connectionRetryAfter is suggested to clients turned away while full
+const connectionRetryAfter = 10 * time.Second
+
+// websocketSubprotocols are the signalling framings this server speaks. The
+// upgrader echoes back the first one the client offers. Clients that offer
+// none get the original framing.
+var websocketSubprotocols = []string{"webxash.v1"}
+
+// closeWithPolicyViolation tells a misbehaving client why it is dropped
+func closeWithPolicyViolation(c *threadSafeWriter, reason string) {
+	msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(web.ControlWriteWait)) //nolint
+}
+
+// websocketsPerIP counts open WebSocket connections per client address
+var websocketsPerIP = web.NewIPConnections()
+
+// rejectConnection answers an upgrade request that can't be served right now
+func rejectConnection(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(connectionRetryAfter.Seconds())))
+	web.WriteJSONError(w, status, code, msg)
+}
+
+// websocketHub holds the signalling connections shutdown says goodbye to
+var websocketHub = web.NewHub()